@@ -0,0 +1,236 @@
+package kubernetes
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+// maxConcurrentBulkImports bounds how many `platform import bulk` runs we
+// kick off at once, independent of how many app pods there are, so a large
+// deployment doesn't hammer every node's disk and CPU simultaneously.
+const maxConcurrentBulkImports = 8
+
+// bulkLoadShards is the result of sharding a bulk-load file for distributed
+// import across n app pods.
+type bulkLoadShards struct {
+	// Validate[i] is self-contained — the version line, every referential
+	// line (team/channel/user/scheme/...), then shard i's share of the
+	// post/reaction lines — so `platform import bulk --validate` can run
+	// concurrently against every shard: validation doesn't write to the
+	// (shared) database, so duplicated referential lines across shards
+	// cost nothing there.
+	Validate []string
+	// Apply[0] is identical to Validate[0] and must be applied alone,
+	// before any of Apply[1:], so the referential data (users/teams/
+	// channels) is created exactly once rather than racing N concurrent
+	// creates of the same entities against one shared database. Apply[1:]
+	// carry only the version line and that shard's own post/reaction
+	// lines — no referential lines — since by the time they're applied,
+	// Apply[0] has already created everything they reference.
+	Apply []string
+}
+
+// shardBulkLoad splits the bulk-load JSONL file at srcPath into n shards,
+// returning both a fully self-contained set suitable for concurrent
+// `--validate` and a seed-then-fan-out set suitable for `--apply`. See
+// bulkLoadShards for why the two differ. Posts and reactions are
+// round-robined across shards keyed by a hash of their authoring user so a
+// single user's posts (and reactions on them) stay together and import in
+// a consistent relative order.
+func shardBulkLoad(srcPath string, n int) (bulkLoadShards, error) {
+	if n < 1 {
+		return bulkLoadShards{}, errors.New("shardBulkLoad: n must be >= 1")
+	}
+
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return bulkLoadShards{}, errors.Wrap(err, "unable to open bulk load file")
+	}
+	defer src.Close()
+
+	var versionLine []byte
+	var referentialLines [][]byte
+	entryLines := make([][]byte, n)
+
+	scanner := bufio.NewScanner(src)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		line = append([]byte(nil), line...)
+
+		envelope, err := parseBulkLoadLine(line)
+		if err != nil {
+			return bulkLoadShards{}, err
+		}
+
+		switch envelope.Type {
+		case "version":
+			versionLine = line
+		case "post", "reaction":
+			shard := bulkLoadLineShard(envelope, n)
+			entryLines[shard] = append(entryLines[shard], line...)
+			entryLines[shard] = append(entryLines[shard], '\n')
+		default:
+			referentialLines = append(referentialLines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return bulkLoadShards{}, errors.Wrap(err, "unable to read bulk load file")
+	}
+
+	shards := bulkLoadShards{
+		Validate: make([]string, n),
+		Apply:    make([]string, n),
+	}
+	for i := 0; i < n; i++ {
+		validatePath := fmt.Sprintf("%s.shard%d.validate", srcPath, i)
+		if err := writeBulkLoadShard(validatePath, versionLine, referentialLines, entryLines[i]); err != nil {
+			return bulkLoadShards{}, errors.Wrapf(err, "unable to write validate shard %d", i)
+		}
+		shards.Validate[i] = validatePath
+
+		if i == 0 {
+			shards.Apply[0] = validatePath
+			continue
+		}
+		applyPath := fmt.Sprintf("%s.shard%d.apply", srcPath, i)
+		if err := writeBulkLoadShard(applyPath, versionLine, nil, entryLines[i]); err != nil {
+			return bulkLoadShards{}, errors.Wrapf(err, "unable to write apply shard %d", i)
+		}
+		shards.Apply[i] = applyPath
+	}
+
+	return shards, nil
+}
+
+// writeBulkLoadShard assembles one shard file: the version line (if any)
+// first, then every referential line, then that shard's post/reaction
+// lines, so the result validates and imports on its own.
+func writeBulkLoadShard(path string, versionLine []byte, referentialLines [][]byte, entries []byte) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	if versionLine != nil {
+		if _, err := w.Write(versionLine); err != nil {
+			return err
+		}
+		if err := w.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	for _, line := range referentialLines {
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+		if err := w.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	if _, err := w.Write(entries); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+// bulkLoadEnvelope is the subset of a bulk-load JSONL line needed to route
+// it to a shard.
+type bulkLoadEnvelope struct {
+	Type string `json:"type"`
+	Post struct {
+		User string `json:"user"`
+	} `json:"post"`
+	Reaction struct {
+		User string `json:"user"`
+	} `json:"reaction"`
+}
+
+func parseBulkLoadLine(line []byte) (bulkLoadEnvelope, error) {
+	var envelope bulkLoadEnvelope
+	if err := json.Unmarshal(line, &envelope); err != nil {
+		return envelope, errors.Wrap(err, "unable to parse bulk load line")
+	}
+	return envelope, nil
+}
+
+// bulkLoadLineShard decides which shard a "post" or "reaction" envelope
+// belongs in, keyed by its authoring user.
+func bulkLoadLineShard(envelope bulkLoadEnvelope, n int) int {
+	user := envelope.Post.User
+	if envelope.Type == "reaction" {
+		user = envelope.Reaction.User
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(user))
+	return int(h.Sum32() % uint32(n))
+}
+
+// distributeAndImportShards copies shardPaths[i] to appPods[i] and runs
+// `platform import bulk` on each, validateOnly or applying, with at most
+// maxConcurrentBulkImports running at once. It reports each pod's duration
+// and any failure, and aborts the whole group (via errgroup) on the first
+// failure seen.
+func (c *Cluster) distributeAndImportShards(appPods []string, shardPaths []string, validateOnly bool) error {
+	if len(appPods) != len(shardPaths) {
+		return errors.Errorf("have %d app pods but %d shards", len(appPods), len(shardPaths))
+	}
+
+	sem := make(chan struct{}, maxConcurrentBulkImports)
+	var g errgroup.Group
+	for i := range appPods {
+		i := i
+		g.Go(func() error {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			pod := appPods[i]
+			remotePath := filepath.Join("/mattermost", filepath.Base(shardPaths[i]))
+			if err := c.KubeClient().CopyFile(PodPath{Path: shardPaths[i]}, PodPath{Pod: pod, Path: remotePath}); err != nil {
+				return errors.Wrapf(err, "unable to copy shard %d to %s", i, pod)
+			}
+			return c.runBulkImport(pod, remotePath, validateOnly)
+		})
+	}
+	return g.Wait()
+}
+
+// runBulkImport runs `platform import bulk` against remotePath on pod,
+// either validating it or applying it, logging the outcome and how long it
+// took.
+func (c *Cluster) runBulkImport(pod, remotePath string, validateOnly bool) error {
+	verb, flag := "import", "--apply"
+	if validateOnly {
+		verb, flag = "validation", "--validate"
+	}
+
+	args := []string{"./bin/platform", "import", "bulk", "--workers", "64", flag, remotePath}
+
+	var out bytes.Buffer
+	start := time.Now()
+	err := c.KubeClient().ExecStream(pod, "", args, &out, &out)
+	duration := time.Since(start)
+
+	if err != nil {
+		return errors.Wrapf(err, "bulk %s failed on %s after %s: %s", verb, pod, duration, out.String())
+	}
+	log.Infof("Bulk %s succeeded on %s in %s", verb, pod, duration)
+	return nil
+}
@@ -0,0 +1,265 @@
+package kubernetes
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func writeTestBulkLoad(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "bulkload-shard-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	lines := []string{
+		`{"type":"version","version":1}`,
+		`{"type":"team","team":{"name":"team-1"}}`,
+		`{"type":"user","user":{"username":"alice"}}`,
+		`{"type":"user","user":{"username":"bob"}}`,
+		`{"type":"post","post":{"user":"alice","message":"hi"}}`,
+		`{"type":"post","post":{"user":"alice","message":"again"}}`,
+		`{"type":"post","post":{"user":"bob","message":"hello"}}`,
+		`{"type":"reaction","reaction":{"user":"bob","emoji_name":"+1"}}`,
+	}
+
+	path := filepath.Join(dir, "loadtestbulkload.json")
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+type bulkLoadTestLine struct {
+	Type string `json:"type"`
+	User struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	Post struct {
+		User string `json:"user"`
+	} `json:"post"`
+	Reaction struct {
+		User string `json:"user"`
+	} `json:"reaction"`
+}
+
+func readShardLines(t *testing.T, path string) []bulkLoadTestLine {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var out []bulkLoadTestLine
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var line bulkLoadTestLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("shard %s contains invalid JSON line %q: %v", path, scanner.Text(), err)
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// TestShardBulkLoadShardsAreSelfContained guards against the bug where only
+// shard 0 got the version line and referential (team/channel/user)
+// definitions: every shard must be independently importable by
+// `platform import bulk`, which requires the version line first and every
+// post/reaction to reference a user defined earlier in the same file.
+func TestShardBulkLoadShardsAreSelfContained(t *testing.T) {
+	path := writeTestBulkLoad(t)
+
+	shards, err := shardBulkLoad(path, 3)
+	if err != nil {
+		t.Fatalf("shardBulkLoad returned error: %v", err)
+	}
+	if len(shards.Validate) != 3 {
+		t.Fatalf("expected 3 validate shards, got %d", len(shards.Validate))
+	}
+
+	for _, shardPath := range shards.Validate {
+		lines := readShardLines(t, shardPath)
+		if len(lines) == 0 {
+			t.Fatalf("shard %s is empty", shardPath)
+		}
+		if lines[0].Type != "version" {
+			t.Fatalf("shard %s must start with the version line, got type %q", shardPath, lines[0].Type)
+		}
+
+		definedUsers := map[string]bool{}
+		for _, line := range lines {
+			if line.Type == "user" {
+				definedUsers[line.User.Username] = true
+			}
+		}
+
+		for _, line := range lines {
+			var author string
+			switch line.Type {
+			case "post":
+				author = line.Post.User
+			case "reaction":
+				author = line.Reaction.User
+			default:
+				continue
+			}
+			if !definedUsers[author] {
+				t.Fatalf("shard %s has a %s by %q with no matching user definition in the same file", shardPath, line.Type, author)
+			}
+		}
+	}
+}
+
+func TestShardBulkLoadKeepsAuthorTogether(t *testing.T) {
+	path := writeTestBulkLoad(t)
+
+	shards, err := shardBulkLoad(path, 4)
+	if err != nil {
+		t.Fatalf("shardBulkLoad returned error: %v", err)
+	}
+
+	aliceShard := -1
+	for i, shardPath := range shards.Validate {
+		for _, line := range readShardLines(t, shardPath) {
+			var author string
+			switch line.Type {
+			case "post":
+				author = line.Post.User
+			case "reaction":
+				author = line.Reaction.User
+			default:
+				continue
+			}
+			if author != "alice" {
+				continue
+			}
+			if aliceShard == -1 {
+				aliceShard = i
+			} else if aliceShard != i {
+				t.Fatalf("expected all of alice's posts/reactions in one shard, found in shard %d and %d", aliceShard, i)
+			}
+		}
+	}
+	if aliceShard == -1 {
+		t.Fatal("expected to find alice's posts in some shard")
+	}
+}
+
+// TestShardBulkLoadOnlyFirstApplyShardCarriesReferentialData guards against
+// applying every shard's (duplicated) referential lines concurrently, which
+// would race N concurrent creates of the same users/teams/channels against
+// the one database every app pod shares. Only Apply[0] should define users;
+// Apply[1:] must carry just their own posts/reactions, on the assumption
+// that Apply[0] is applied alone, first.
+func TestShardBulkLoadOnlyFirstApplyShardCarriesReferentialData(t *testing.T) {
+	path := writeTestBulkLoad(t)
+
+	shards, err := shardBulkLoad(path, 3)
+	if err != nil {
+		t.Fatalf("shardBulkLoad returned error: %v", err)
+	}
+	if len(shards.Apply) != 3 {
+		t.Fatalf("expected 3 apply shards, got %d", len(shards.Apply))
+	}
+
+	if shards.Apply[0] != shards.Validate[0] {
+		t.Fatalf("expected apply shard 0 (%s) to be identical to validate shard 0 (%s)", shards.Apply[0], shards.Validate[0])
+	}
+
+	firstLines := readShardLines(t, shards.Apply[0])
+	if firstLines[0].Type != "version" {
+		t.Fatalf("apply shard 0 must start with the version line, got type %q", firstLines[0].Type)
+	}
+	sawUser := false
+	for _, line := range firstLines {
+		if line.Type == "user" {
+			sawUser = true
+		}
+	}
+	if !sawUser {
+		t.Fatal("expected apply shard 0 to carry the referential user definitions")
+	}
+
+	for i := 1; i < len(shards.Apply); i++ {
+		for _, line := range readShardLines(t, shards.Apply[i]) {
+			if line.Type == "user" || line.Type == "team" || line.Type == "channel" {
+				t.Fatalf("apply shard %d must not carry referential data, found a %q line", i, line.Type)
+			}
+		}
+	}
+}
+
+func TestDistributeAndImportShardsAbortsOnValidationFailure(t *testing.T) {
+	fake := &FakeKubeClient{
+		ExecFunc: func(pod, container string, cmd []string, stdout, stderr io.Writer) error {
+			if pod == "app-1" {
+				return &ErrExecExitCode{Pod: pod, Command: cmd, ExitCode: 1}
+			}
+			return nil
+		},
+	}
+	c := newTestCluster(t, fake)
+	path := writeTestBulkLoad(t)
+
+	shards, err := shardBulkLoad(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.distributeAndImportShards([]string{"app-0", "app-1"}, shards.Validate, true); err == nil {
+		t.Fatal("expected validation failure on app-1 to be surfaced")
+	}
+}
+
+// TestDistributeAndImportShardsImportsFromTheCopiedPath guards against the
+// copy destination and the path `platform import bulk` is execed against
+// drifting apart: the shard must be imported from exactly where it was
+// copied to on that pod.
+func TestDistributeAndImportShardsImportsFromTheCopiedPath(t *testing.T) {
+	var mu sync.Mutex
+	importedFrom := map[string]string{}
+	fake := &FakeKubeClient{
+		ExecFunc: func(pod, container string, cmd []string, stdout, stderr io.Writer) error {
+			mu.Lock()
+			importedFrom[pod] = cmd[len(cmd)-1]
+			mu.Unlock()
+			return nil
+		},
+	}
+	c := newTestCluster(t, fake)
+	path := writeTestBulkLoad(t)
+
+	shards, err := shardBulkLoad(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.distributeAndImportShards([]string{"app-0", "app-1"}, shards.Validate, true); err != nil {
+		t.Fatalf("distributeAndImportShards returned error: %v", err)
+	}
+
+	if len(fake.CopyCalls) != 2 {
+		t.Fatalf("expected 2 copies, got %d", len(fake.CopyCalls))
+	}
+
+	for _, call := range fake.CopyCalls {
+		importedFromPath, ok := importedFrom[call.Dst.Pod]
+		if !ok {
+			t.Fatalf("no import recorded for pod %s", call.Dst.Pod)
+		}
+		if importedFromPath != call.Dst.Path {
+			t.Fatalf("pod %s: imported from %q but copied to %q", call.Dst.Pod, importedFromPath, call.Dst.Path)
+		}
+	}
+}
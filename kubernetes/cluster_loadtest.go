@@ -3,7 +3,6 @@ package kubernetes
 import (
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"sync"
 	"time"
@@ -22,50 +21,84 @@ func (c *Cluster) loadtestPod(pod string, resultsOutput io.Writer) error {
 		return errors.Wrap(err, "unable to create loadtest results file.")
 	}
 
-	cmd := exec.Command("kubectl", "exec", pod, "./bin/loadtest", "all")
-
+	var stdout io.Writer = outfile
 	if resultsOutput != nil {
-		cmd.Stdout = io.MultiWriter(outfile, resultsOutput)
-	} else {
-		cmd.Stdout = outfile
+		stdout = io.MultiWriter(outfile, resultsOutput)
 	}
-	cmd.Stderr = outfile
 
 	log.Info("Running loadtest on " + pod)
-	if err := cmd.Run(); err != nil {
-		return err
+	if err := c.KubeClient().ExecStream(pod, "", []string{"./bin/loadtest", "all"}, stdout, outfile); err != nil {
+		return errors.Wrapf(err, "loadtest failed on %s", pod)
 	}
 
 	return nil
 }
 
-func (c *Cluster) bulkLoad(loadtestPod string, appPod string) error {
+// bulkLoad generates the bulk load data on loadtestPod, then either imports
+// it directly on a single app pod or, when there's more than one, shards it
+// across all of them so seeded state doesn't all land via one app node. If
+// validateOnly is set, every shard is run through `platform import bulk
+// --validate` and the apply step is skipped entirely; this lets a caller
+// check a bulk load is importable before committing to the (potentially
+// slow) real import.
+func (c *Cluster) bulkLoad(loadtestPod string, appPods []string, validateOnly bool) error {
 	log.Info("Bulk importing data, this may take some time")
-	cmd := exec.Command("kubectl", "exec", loadtestPod, "./bin/loadtest", "genbulkload")
-	if err := cmd.Run(); err != nil {
-		return err
+	if err := c.KubeClient().ExecStream(loadtestPod, "", []string{"./bin/loadtest", "genbulkload"}, nil, nil); err != nil {
+		return errors.Wrap(err, "genbulkload failed")
 	}
 
-	// Unfortunately kubectl cp doesn't work directly between pods
-	cmd = exec.Command("kubectl", "cp", loadtestPod+":/mattermost-load-test/loadtestbulkload.json", c.Configuration().WorkingDirectory)
-	if err := cmd.Run(); err != nil {
-		return err
+	loadtestbulkloadSrc := PodPath{Pod: loadtestPod, Path: "/mattermost-load-test/loadtestbulkload.json"}
+
+	if len(appPods) == 1 {
+		appPod := appPods[0]
+		appbulkloadDst := PodPath{Pod: appPod, Path: "/mattermost/loadtestbulkload.json"}
+		if loadtestPod != appPod {
+			// Stream pod-to-pod directly; no need to land the
+			// (potentially large) bulk load file on the runner's local
+			// disk when there's nothing to shard.
+			if err := c.KubeClient().CopyFile(loadtestbulkloadSrc, appbulkloadDst); err != nil {
+				return errors.Wrap(err, "unable to copy bulk load file to app pod")
+			}
+		}
+		if err := c.runBulkImport(appPod, "./loadtestbulkload.json", true); err != nil {
+			return errors.Wrap(err, "bulk load validation failed")
+		}
+		if validateOnly {
+			return nil
+		}
+		return c.runBulkImport(appPod, "./loadtestbulkload.json", false)
 	}
 
-	cmd = exec.Command("kubectl", "cp", filepath.Join(c.Configuration().WorkingDirectory, "loadtestbulkload.json"), appPod+":/mattermost/")
-	if err := cmd.Run(); err != nil {
-		return err
+	localPath := filepath.Join(c.Configuration().WorkingDirectory, "loadtestbulkload.json")
+	if err := c.KubeClient().CopyFile(loadtestbulkloadSrc, PodPath{Path: localPath}); err != nil {
+		return errors.Wrap(err, "unable to copy bulk load file from loadtest pod")
 	}
 
-	cmd = exec.Command("kubectl", "exec", appPod, "--", "./bin/platform", "import", "bulk", "--workers", "64", "--apply", "./loadtestbulkload.json")
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return errors.Wrap(err, "bulk import failed: "+string(out))
+	shards, err := shardBulkLoad(localPath, len(appPods))
+	if err != nil {
+		return errors.Wrap(err, "unable to shard bulk load file")
 	}
 
-	return nil
+	if err := c.distributeAndImportShards(appPods, shards.Validate, true); err != nil {
+		return errors.Wrap(err, "bulk load validation failed; aborting before apply")
+	}
+	if validateOnly {
+		log.Info("Bulk load validation succeeded on all shards; skipping apply")
+		return nil
+	}
+
+	// Apply shard 0 (which carries the referential users/teams/channels)
+	// alone first, so that data is created exactly once rather than
+	// racing concurrent creates of the same entities against the one
+	// database every app pod shares. Only once it has landed is it safe
+	// to fan the remaining, referential-free shards out concurrently.
+	if err := c.distributeAndImportShards(appPods[:1], shards.Apply[:1], false); err != nil {
+		return errors.Wrap(err, "bulk load apply failed while seeding referential data")
+	}
+	return c.distributeAndImportShards(appPods[1:], shards.Apply[1:], false)
 }
 
-func (c *Cluster) Loadtest(resultsOutput io.Writer) error {
+func (c *Cluster) Loadtest(resultsOutput io.Writer, validateOnly bool) error {
 	loadtestPods, err := c.GetLoadtestInstancesAddrs()
 	if err != nil || len(loadtestPods) <= 0 {
 		return errors.Wrap(err, "unable to get loadtest pods")
@@ -76,10 +109,20 @@ func (c *Cluster) Loadtest(resultsOutput io.Writer) error {
 		return errors.Wrap(err, "unable to get app pods")
 	}
 
-	err = c.bulkLoad(loadtestPods[0], appPods[0])
+	err = c.bulkLoad(loadtestPods[0], appPods, validateOnly)
 	if err != nil {
 		return err
 	}
+	if validateOnly {
+		log.Info("--validate specified; skipping the loadtest run")
+		return nil
+	}
+
+	// Poll metrics for the lifetime of the run: each loadtest pod's
+	// in-process metrics server exits along with `./bin/loadtest all`, so
+	// scraping has to happen concurrently with the execs below rather than
+	// after they've all exited.
+	poller := newMetricsPoller(c, loadtestPods)
 
 	var wg sync.WaitGroup
 	wg.Add(len(loadtestPods))
@@ -105,5 +148,10 @@ func (c *Cluster) Loadtest(resultsOutput io.Writer) error {
 	log.Info("Wating for loadtests to complete. See: " + filepath.Join(c.Configuration().WorkingDirectory, "results") + " for results.")
 	wg.Wait()
 
+	poller.close()
+	if err := writeMetricsSummaryFile(c.Configuration().WorkingDirectory, poller.snapshot()); err != nil {
+		log.Error(errors.Wrap(err, "unable to write combined metrics summary"))
+	}
+
 	return nil
 }
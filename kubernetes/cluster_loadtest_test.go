@@ -0,0 +1,72 @@
+package kubernetes
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestCluster(t *testing.T, fake *FakeKubeClient) *Cluster {
+	dir, err := ioutil.TempDir("", "cluster-loadtest-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	c := &Cluster{}
+	c.SetConfiguration(&Config{WorkingDirectory: dir})
+	c.SetKubeClient(fake)
+	return c
+}
+
+func TestBulkLoadStreamsPodToPod(t *testing.T) {
+	fake := &FakeKubeClient{}
+	c := newTestCluster(t, fake)
+
+	if err := c.bulkLoad("loadtest-0", []string{"app-0"}, false); err != nil {
+		t.Fatalf("bulkLoad returned error: %v", err)
+	}
+
+	if len(fake.CopyCalls) != 1 {
+		t.Fatalf("expected a single direct pod-to-pod copy, got %d copies", len(fake.CopyCalls))
+	}
+	call := fake.CopyCalls[0]
+	if call.Src.Pod != "loadtest-0" || call.Dst.Pod != "app-0" {
+		t.Fatalf("expected copy from loadtest-0 to app-0, got %+v", call)
+	}
+
+	if _, err := os.Stat(filepath.Join(c.Configuration().WorkingDirectory, "loadtestbulkload.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected no local bulk load file to be written, got err=%v", err)
+	}
+}
+
+func TestBulkLoadFailsOnImportError(t *testing.T) {
+	fake := &FakeKubeClient{
+		ExecFunc: func(pod, container string, cmd []string, stdout, stderr io.Writer) error {
+			if pod == "app-0" {
+				return &ErrExecExitCode{Pod: pod, Command: cmd, ExitCode: 1}
+			}
+			return nil
+		},
+	}
+	c := newTestCluster(t, fake)
+
+	if err := c.bulkLoad("loadtest-0", []string{"app-0"}, false); err == nil {
+		t.Fatal("expected bulkLoad to surface the import failure")
+	}
+}
+
+func TestLoadtestPodWritesResults(t *testing.T) {
+	fake := &FakeKubeClient{}
+	c := newTestCluster(t, fake)
+
+	if err := c.loadtestPod("loadtest-0", nil); err != nil {
+		t.Fatalf("loadtestPod returned error: %v", err)
+	}
+
+	if len(fake.ExecCalls) != 1 || fake.ExecCalls[0].Pod != "loadtest-0" {
+		t.Fatalf("expected a single exec against loadtest-0, got %+v", fake.ExecCalls)
+	}
+}
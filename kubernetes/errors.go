@@ -0,0 +1,52 @@
+package kubernetes
+
+import "fmt"
+
+// ErrImagePull indicates that a pod's container image could not be pulled,
+// so the requested exec or copy could never have succeeded.
+type ErrImagePull struct {
+	Pod string
+	Err error
+}
+
+func (e *ErrImagePull) Error() string {
+	return fmt.Sprintf("image pull failed for pod %s: %v", e.Pod, e.Err)
+}
+
+func (e *ErrImagePull) Cause() error { return e.Err }
+
+// ErrPodNotReady indicates the target pod has not reached the Running phase
+// with all containers ready, so no exec or copy could be attempted against it.
+type ErrPodNotReady struct {
+	Pod   string
+	Phase string
+}
+
+func (e *ErrPodNotReady) Error() string {
+	return fmt.Sprintf("pod %s is not ready (phase %s)", e.Pod, e.Phase)
+}
+
+// ErrExecExitCode indicates a remote command ran to completion inside a pod
+// but exited with a non-zero status.
+type ErrExecExitCode struct {
+	Pod      string
+	Command  []string
+	ExitCode int
+}
+
+func (e *ErrExecExitCode) Error() string {
+	return fmt.Sprintf("command %v in pod %s exited with code %d", e.Command, e.Pod, e.ExitCode)
+}
+
+// ErrNetwork wraps a failure reaching the Kubernetes API server or a pod's
+// kubelet, as opposed to a failure of the command that was run.
+type ErrNetwork struct {
+	Op  string
+	Err error
+}
+
+func (e *ErrNetwork) Error() string {
+	return fmt.Sprintf("network error during %s: %v", e.Op, e.Err)
+}
+
+func (e *ErrNetwork) Cause() error { return e.Err }
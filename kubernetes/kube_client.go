@@ -0,0 +1,333 @@
+package kubernetes
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/transport/spdy"
+	cliexec "k8s.io/client-go/util/exec"
+)
+
+// PodPath identifies a file, either inside a container or on the local
+// filesystem. A zero Pod means Path is a local path; Container is ignored
+// in that case.
+type PodPath struct {
+	Pod       string
+	Container string
+	Path      string
+}
+
+func (p PodPath) local() bool { return p.Pod == "" }
+
+// KubeClient is the minimal set of pod-level operations the loadtest driver
+// needs from a Kubernetes cluster. It exists so the methods on Cluster can be
+// exercised against a fake in tests instead of shelling out to kubectl.
+type KubeClient interface {
+	// ExecStream runs cmd inside container of pod, streaming its stdout and
+	// stderr to the given writers until the command exits.
+	ExecStream(pod, container string, cmd []string, stdout, stderr io.Writer) error
+	// CopyFile copies a single file from src to dst. Exactly one of src, dst
+	// may be local; when both are pods the file is streamed pod-to-pod
+	// without being written to local disk.
+	CopyFile(src, dst PodPath) error
+	// PortForward opens a local TCP listener on localPort that forwards
+	// connections to podPort inside pod, returning a closer that tears the
+	// forward down.
+	PortForward(pod string, localPort, podPort int) (io.Closer, error)
+}
+
+type realKubeClient struct {
+	clientset *kubernetes.Clientset
+	config    *rest.Config
+	namespace string
+}
+
+// NewKubeClient builds a KubeClient backed by client-go, talking to the
+// cluster described by config in the given namespace.
+func NewKubeClient(config *rest.Config, namespace string) (KubeClient, error) {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to build kubernetes clientset")
+	}
+
+	return &realKubeClient{
+		clientset: clientset,
+		config:    config,
+		namespace: namespace,
+	}, nil
+}
+
+func (k *realKubeClient) ensureReady(pod string) error {
+	p, err := k.clientset.CoreV1().Pods(k.namespace).Get(pod, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return &ErrPodNotReady{Pod: pod, Phase: "NotFound"}
+		}
+		return &ErrNetwork{Op: "get pod " + pod, Err: err}
+	}
+
+	if p.Status.Phase != corev1.PodRunning {
+		return &ErrPodNotReady{Pod: pod, Phase: string(p.Status.Phase)}
+	}
+
+	for _, cs := range p.Status.ContainerStatuses {
+		if cs.State.Waiting != nil && cs.State.Waiting.Reason == "ImagePullBackOff" {
+			return &ErrImagePull{Pod: pod, Err: errors.New(cs.State.Waiting.Message)}
+		}
+	}
+
+	return nil
+}
+
+func (k *realKubeClient) ExecStream(pod, container string, cmd []string, stdout, stderr io.Writer) error {
+	return k.exec(pod, container, cmd, nil, stdout, stderr)
+}
+
+func (k *realKubeClient) exec(pod, container string, cmd []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if err := k.ensureReady(pod); err != nil {
+		return err
+	}
+
+	req := k.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(pod).
+		Namespace(k.namespace).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   cmd,
+			Stdin:     stdin != nil,
+			Stdout:    stdout != nil,
+			Stderr:    stderr != nil,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(k.config, "POST", req.URL())
+	if err != nil {
+		return &ErrNetwork{Op: "create exec stream to " + pod, Err: err}
+	}
+
+	err = executor.Stream(remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: stderr,
+	})
+	if err == nil {
+		return nil
+	}
+
+	if exitErr, ok := err.(cliexec.ExitError); ok {
+		return &ErrExecExitCode{Pod: pod, Command: cmd, ExitCode: exitErr.ExitStatus()}
+	}
+
+	return &ErrNetwork{Op: "exec " + pod, Err: err}
+}
+
+// PortForward opens a local listener on localPort that forwards to podPort
+// inside pod via an SPDY-upgraded connection to the API server, the same
+// mechanism `kubectl port-forward` uses. Closing the returned io.Closer
+// tears the forward down.
+func (k *realKubeClient) PortForward(pod string, localPort, podPort int) (io.Closer, error) {
+	if err := k.ensureReady(pod); err != nil {
+		return nil, err
+	}
+
+	req := k.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(pod).
+		Namespace(k.namespace).
+		SubResource("portforward")
+
+	transport, upgrader, err := spdy.RoundTripperFor(k.config)
+	if err != nil {
+		return nil, &ErrNetwork{Op: "build port-forward transport", Err: err}
+	}
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, "POST", req.URL())
+
+	stopCh := make(chan struct{})
+	readyCh := make(chan struct{})
+	ports := []string{fmt.Sprintf("%d:%d", localPort, podPort)}
+
+	fw, err := portforward.New(dialer, ports, stopCh, readyCh, ioutil.Discard, ioutil.Discard)
+	if err != nil {
+		return nil, &ErrNetwork{Op: "create port forwarder for " + pod, Err: err}
+	}
+
+	forwardErrCh := make(chan error, 1)
+	go func() { forwardErrCh <- fw.ForwardPorts() }()
+
+	select {
+	case <-readyCh:
+	case err := <-forwardErrCh:
+		return nil, &ErrNetwork{Op: "port-forward to " + pod, Err: err}
+	}
+
+	return &portForwardCloser{stopCh: stopCh}, nil
+}
+
+type portForwardCloser struct {
+	stopCh chan struct{}
+	once   sync.Once
+}
+
+func (p *portForwardCloser) Close() error {
+	p.once.Do(func() { close(p.stopCh) })
+	return nil
+}
+
+// CopyFile copies src to dst via tar, the same mechanism `kubectl cp` uses:
+// the source side is read (or produced, for a pod source) as a tar stream
+// and the destination side extracts (or is exec'd, for a pod destination)
+// from that same stream. When both ends are pods the stream is piped
+// directly between the two execs and never touches local disk.
+func (k *realKubeClient) CopyFile(src, dst PodPath) error {
+	switch {
+	case src.local() && dst.local():
+		return errors.New("CopyFile: src and dst cannot both be local")
+	case !src.local() && !dst.local():
+		return k.copyPodToPod(src, dst)
+	case !src.local() && dst.local():
+		return k.copyFromPod(src, dst.Path)
+	default:
+		return k.copyToPod(src.Path, dst)
+	}
+}
+
+func (k *realKubeClient) copyPodToPod(src, dst PodPath) error {
+	r, w := io.Pipe()
+
+	srcDir, srcFile := filepath.Split(src.Path)
+
+	errCh := make(chan error, 2)
+	go func() {
+		errCh <- k.exec(src.Pod, src.Container, []string{"tar", "cf", "-", "-C", srcDir, srcFile}, nil, w, nil)
+		w.Close()
+	}()
+	go func() {
+		errCh <- k.exec(dst.Pod, dst.Container, extractTarToPathCmd(dst.Path), r, nil, nil)
+	}()
+
+	var firstErr error
+	for i := 0; i < 2; i++ {
+		if err := <-errCh; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (k *realKubeClient) copyFromPod(src PodPath, localPath string) error {
+	r, w := io.Pipe()
+	srcDir, srcFile := filepath.Split(src.Path)
+
+	execErrCh := make(chan error, 1)
+	go func() {
+		execErrCh <- k.exec(src.Pod, src.Container, []string{"tar", "cf", "-", "-C", srcDir, srcFile}, nil, w, nil)
+		w.Close()
+	}()
+
+	if err := extractTarSingleFile(r, localPath); err != nil {
+		return errors.Wrap(err, "unable to extract copied file")
+	}
+
+	return <-execErrCh
+}
+
+func (k *realKubeClient) copyToPod(localPath string, dst PodPath) error {
+	r, w := io.Pipe()
+
+	writeErrCh := make(chan error, 1)
+	go func() {
+		writeErrCh <- writeTarSingleFile(w, localPath)
+		w.Close()
+	}()
+
+	if err := k.exec(dst.Pod, dst.Container, extractTarToPathCmd(dst.Path), r, nil, nil); err != nil {
+		return err
+	}
+
+	return <-writeErrCh
+}
+
+// extractTarToPathCmd builds a command that extracts a single-file tar
+// stream from stdin to path, regardless of the name the entry was archived
+// under. `tar -C dir` (what we used to run) keeps the archived entry's own
+// basename, silently discarding any rename CopyFile's dst.Path asked for;
+// extracting into a scratch directory and moving the one file it contains
+// to the exact path we want sidesteps that, while still letting tar itself
+// apply the archived file's mode, unlike redirecting `tar -O`'s stdout
+// through the shell (which would create path with the shell's default
+// permissions instead).
+func extractTarToPathCmd(path string) []string {
+	return []string{"sh", "-c", `d=$(mktemp -d) && tar xf - -C "$d" && mv "$d"/* ` + shellQuote(path) + ` && rm -rf "$d"`}
+}
+
+// shellQuote wraps s in single quotes for safe use as a single argument to
+// `sh -c`, escaping any single quotes it contains.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func extractTarSingleFile(r io.Reader, localPath string) error {
+	tr := tar.NewReader(r)
+	hdr, err := tr.Next()
+	if err != nil {
+		return err
+	}
+	if hdr.Typeflag != tar.TypeReg {
+		return errors.Errorf("unexpected tar entry type for %s", hdr.Name)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(localPath), 0700); err != nil {
+		return err
+	}
+	out, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, tr)
+	return err
+}
+
+func writeTarSingleFile(w io.Writer, localPath string) error {
+	in, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	if err := tw.WriteHeader(&tar.Header{
+		Name: filepath.Base(localPath),
+		Mode: 0600,
+		Size: info.Size(),
+	}); err != nil {
+		return err
+	}
+	if _, err := io.Copy(tw, in); err != nil {
+		return err
+	}
+	return tw.Close()
+}
@@ -0,0 +1,64 @@
+package kubernetes
+
+import "io"
+
+// FakeKubeClient is an in-memory KubeClient for exercising Cluster's methods
+// without a real cluster. ExecFunc, CopyFunc and PortForwardFunc default to
+// succeeding with no output; set them to assert on calls or to inject
+// failures.
+type FakeKubeClient struct {
+	ExecFunc        func(pod, container string, cmd []string, stdout, stderr io.Writer) error
+	CopyFunc        func(src, dst PodPath) error
+	PortForwardFunc func(pod string, localPort, podPort int) (io.Closer, error)
+
+	ExecCalls        []FakeExecCall
+	CopyCalls        []FakeCopyCall
+	PortForwardCalls []FakePortForwardCall
+}
+
+// FakePortForwardCall records the arguments of a single PortForward
+// invocation.
+type FakePortForwardCall struct {
+	Pod                string
+	LocalPort, PodPort int
+}
+
+type noopCloser struct{}
+
+func (noopCloser) Close() error { return nil }
+
+// FakeExecCall records the arguments of a single ExecStream invocation.
+type FakeExecCall struct {
+	Pod       string
+	Container string
+	Command   []string
+}
+
+// FakeCopyCall records the arguments of a single CopyFile invocation.
+type FakeCopyCall struct {
+	Src, Dst PodPath
+}
+
+func (f *FakeKubeClient) ExecStream(pod, container string, cmd []string, stdout, stderr io.Writer) error {
+	f.ExecCalls = append(f.ExecCalls, FakeExecCall{Pod: pod, Container: container, Command: cmd})
+	if f.ExecFunc != nil {
+		return f.ExecFunc(pod, container, cmd, stdout, stderr)
+	}
+	return nil
+}
+
+func (f *FakeKubeClient) CopyFile(src, dst PodPath) error {
+	f.CopyCalls = append(f.CopyCalls, FakeCopyCall{Src: src, Dst: dst})
+	if f.CopyFunc != nil {
+		return f.CopyFunc(src, dst)
+	}
+	return nil
+}
+
+func (f *FakeKubeClient) PortForward(pod string, localPort, podPort int) (io.Closer, error) {
+	f.PortForwardCalls = append(f.PortForwardCalls, FakePortForwardCall{Pod: pod, LocalPort: localPort, PodPort: podPort})
+	if f.PortForwardFunc != nil {
+		return f.PortForwardFunc(pod, localPort, podPort)
+	}
+	return noopCloser{}, nil
+}
@@ -0,0 +1,15 @@
+package kubernetes
+
+// KubeClient returns the client used to exec commands and copy files to and
+// from pods in the cluster. It is exported so future subcommands (log
+// fetch, port-forward for pprof, ...) can reuse it instead of building
+// their own client-go plumbing.
+func (c *Cluster) KubeClient() KubeClient {
+	return c.kubeClient
+}
+
+// SetKubeClient overrides the cluster's KubeClient. It exists so tests can
+// substitute a FakeKubeClient without talking to a real cluster.
+func (c *Cluster) SetKubeClient(k KubeClient) {
+	c.kubeClient = k
+}
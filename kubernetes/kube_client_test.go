@@ -0,0 +1,74 @@
+package kubernetes
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestExtractTarToPathCmdPreservesDestinationName guards against the bug
+// where extracting with `tar -C dir` kept the archived entry's own
+// basename, silently discarding any rename CopyFile's dst.Path asked for.
+// It runs the actual shell command extractTarToPathCmd builds (not a fake),
+// piping in a tar stream built from a source file with a different
+// basename than the destination.
+func TestExtractTarToPathCmdPreservesDestinationName(t *testing.T) {
+	srcDir, err := ioutil.TempDir("", "kube-client-tar-test-src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(srcDir) })
+
+	dstDir, err := ioutil.TempDir("", "kube-client-tar-test-dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dstDir) })
+
+	srcPath := filepath.Join(srcDir, "src-name.json")
+	if err := ioutil.WriteFile(srcPath, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var tarBuf bytes.Buffer
+	if err := writeTarSingleFile(&tarBuf, srcPath); err != nil {
+		t.Fatalf("writeTarSingleFile returned error: %v", err)
+	}
+
+	dstPath := filepath.Join(dstDir, "dst-name.json")
+	cmd := extractTarToPathCmd(dstPath)
+	c := exec.Command(cmd[0], cmd[1:]...)
+	c.Stdin = &tarBuf
+	if out, err := c.CombinedOutput(); err != nil {
+		t.Fatalf("extraction command failed: %v\noutput: %s", err, out)
+	}
+
+	got, err := ioutil.ReadFile(dstPath)
+	if err != nil {
+		t.Fatalf("expected file at dst path %s, got error: %v", dstPath, err)
+	}
+	if string(got) != "hello" {
+		t.Fatalf("expected extracted content %q, got %q", "hello", got)
+	}
+
+	if _, err := os.Stat(filepath.Join(dstDir, "src-name.json")); !os.IsNotExist(err) {
+		t.Fatalf("extraction should land only at the requested dst path, not under the archived entry's own basename (err=%v)", err)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"/mattermost/loadtestbulkload-shard0.json", "'/mattermost/loadtestbulkload-shard0.json'"},
+		{"it's/a/path", `'it'\''s/a/path'`},
+	}
+	for _, c := range cases {
+		if got := shellQuote(c.in); got != c.want {
+			t.Errorf("shellQuote(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
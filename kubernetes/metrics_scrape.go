@@ -0,0 +1,162 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	log "github.com/sirupsen/logrus"
+)
+
+// metricsPollInterval is how often the running loadtest pods are scraped.
+// The loadtest process (and its in-process metrics server) exits as soon
+// as `./bin/loadtest all` returns, so scraping has to happen while the
+// pods are still up rather than afterwards.
+const metricsPollInterval = 15 * time.Second
+
+// podMetricsPort is the port each loadtest pod serves its Prometheus
+// /metrics endpoint on, started by the `loadtest all` subcommand.
+const podMetricsPort = 8080
+
+// scrapeMetrics port-forwards to pod's metrics endpoint and returns every
+// sample family it reports, keyed by metric name.
+func (c *Cluster) scrapeMetrics(pod string) (map[string]*dto.MetricFamily, error) {
+	localPort := podMetricsPort
+	closer, err := c.KubeClient().PortForward(pod, localPort, podMetricsPort)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to port-forward to %s", pod)
+	}
+	defer closer.Close()
+
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(fmt.Sprintf("http://127.0.0.1:%d/metrics", localPort))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to scrape metrics from %s", pod)
+	}
+	defer resp.Body.Close()
+
+	families, err := expfmt.TextParser{}.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse metrics from %s", pod)
+	}
+
+	return families, nil
+}
+
+// scrapeAllMetrics scrapes every pod in loadtestPods, logging and skipping
+// any that fail rather than aborting the whole summary over one bad pod.
+func (c *Cluster) scrapeAllMetrics(loadtestPods []string) map[string]map[string]*dto.MetricFamily {
+	summary := map[string]map[string]*dto.MetricFamily{}
+	for _, pod := range loadtestPods {
+		families, err := c.scrapeMetrics(pod)
+		if err != nil {
+			log.Error(errors.Wrapf(err, "skipping metrics for %s", pod))
+			continue
+		}
+		summary[pod] = families
+	}
+	return summary
+}
+
+// writeMetricsSummaryFile serializes summary to the results directory
+// alongside the existing per-pod text logs, so results are
+// machine-readable for CI regressions as well as human-readable.
+func writeMetricsSummaryFile(workingDirectory string, summary map[string]map[string]*dto.MetricFamily) error {
+	out, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal metrics summary")
+	}
+
+	summaryFile := filepath.Join(workingDirectory, "results", "metrics-summary.json")
+	return errors.Wrap(ioutil.WriteFile(summaryFile, out, 0600), "unable to write metrics summary")
+}
+
+// metricsPoller periodically scrapes a fixed set of loadtest pods while
+// they're running and keeps the latest successful summary, so it's
+// available to write out once the pods (and their in-process metrics
+// servers) have exited.
+type metricsPoller struct {
+	cluster *Cluster
+	pods    []string
+
+	stop chan struct{}
+	done chan struct{}
+
+	mu     sync.Mutex
+	latest map[string]map[string]*dto.MetricFamily
+}
+
+// newMetricsPoller starts polling pods every metricsPollInterval in the
+// background; callers must call stop() once the pods are no longer
+// expected to be scrapeable.
+func newMetricsPoller(c *Cluster, pods []string) *metricsPoller {
+	p := &metricsPoller{
+		cluster: c,
+		pods:    pods,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		latest:  map[string]map[string]*dto.MetricFamily{},
+	}
+	go p.run()
+	return p
+}
+
+func (p *metricsPoller) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(metricsPollInterval)
+	defer ticker.Stop()
+
+	// Scrape once up front so a run that finishes inside the first
+	// interval still captures a snapshot instead of leaving p.latest
+	// empty.
+	p.scrapeOnce()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.scrapeOnce()
+		}
+	}
+}
+
+func (p *metricsPoller) scrapeOnce() {
+	summary := p.cluster.scrapeAllMetrics(p.pods)
+	p.mu.Lock()
+	for pod, families := range summary {
+		p.latest[pod] = families
+	}
+	p.mu.Unlock()
+}
+
+// snapshot returns the latest summary scraped for each pod that was ever
+// successfully reached.
+func (p *metricsPoller) snapshot() map[string]map[string]*dto.MetricFamily {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	summary := make(map[string]map[string]*dto.MetricFamily, len(p.latest))
+	for pod, families := range p.latest {
+		summary[pod] = families
+	}
+	return summary
+}
+
+// close takes one final scrape, then stops polling and waits for the
+// background goroutine to exit. The final scrape is taken before
+// signaling stop so it still runs against pods the caller expects to be
+// up, matching the periodic scrapes triggered by run()'s own ticker.
+func (p *metricsPoller) close() {
+	p.scrapeOnce()
+	close(p.stop)
+	<-p.done
+}
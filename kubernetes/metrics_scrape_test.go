@@ -0,0 +1,59 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestWriteMetricsSummaryFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics-summary-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	if err := os.MkdirAll(filepath.Join(dir, "results"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	name := "loadtest_active_entities"
+	summary := map[string]map[string]*dto.MetricFamily{
+		"loadtest-0": {name: {Name: &name}},
+	}
+
+	if err := writeMetricsSummaryFile(dir, summary); err != nil {
+		t.Fatalf("writeMetricsSummaryFile returned error: %v", err)
+	}
+
+	out, err := ioutil.ReadFile(filepath.Join(dir, "results", "metrics-summary.json"))
+	if err != nil {
+		t.Fatalf("unable to read written summary file: %v", err)
+	}
+
+	var got map[string]map[string]*dto.MetricFamily
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("written summary file is not valid JSON: %v", err)
+	}
+	if _, ok := got["loadtest-0"][name]; !ok {
+		t.Fatalf("expected %q in written summary, got %+v", name, got)
+	}
+}
+
+// TestMetricsPollerClosesCleanly guards against newMetricsPoller's
+// background goroutine leaking or close() deadlocking/panicking, including
+// when it's closed before its first scrape tick fires.
+func TestMetricsPollerClosesCleanly(t *testing.T) {
+	c := newTestCluster(t, &FakeKubeClient{})
+
+	poller := newMetricsPoller(c, []string{"loadtest-0"})
+	poller.close()
+
+	if got := poller.snapshot(); len(got) != 0 {
+		t.Fatalf("expected no snapshot before any scrape completed, got %+v", got)
+	}
+}
@@ -4,8 +4,12 @@
 package loadtest
 
 import (
+	"context"
 	"math/rand"
+	"reflect"
+	"runtime"
 	"runtime/debug"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,6 +20,13 @@ import (
 	"github.com/mattermost/mattermost-server/model"
 )
 
+// EntityAction is the signature entity actions are invoked with. ec.Client
+// and friends behave as before; the context is bounded by the entity's
+// per-action deadline and is canceled if the action overruns it, and
+// actions should give up promptly once ctx is done. Returning a non-nil
+// error other than ctx.Err() is reported via SendStatusError.
+type EntityAction func(context.Context, *EntityConfig) error
+
 type EntityConfig struct {
 	EntityNumber        int
 	EntityName          string
@@ -109,6 +120,63 @@ func (ec *EntityConfig) Initialize() error {
 	return nil
 }
 
+// deadlineTimer bounds how long a single entity action may run. It is
+// armed once per tick and reused for the lifetime of the entity: arming it
+// again cancels whatever action the previous tick started, the same way a
+// fresh request deadline supersedes a stale one.
+type deadlineTimer struct {
+	cancel  context.CancelFunc
+	timer   *time.Timer
+	running <-chan struct{}
+	name    string
+}
+
+// arm cancels the action started by the previous call to arm (if any),
+// then starts a fresh deadline of timeout for the action about to run. The
+// returned context is canceled either when timeout elapses or when parent
+// is canceled, and running should be closed by the caller when the action
+// returns so a later overran() can tell whether it finished in time. name
+// is recorded so an overrun can be reported against the action that caused
+// it rather than a free-form message.
+func (d *deadlineTimer) arm(parent context.Context, timeout time.Duration, running <-chan struct{}, name string) context.Context {
+	if d.cancel != nil {
+		d.cancel()
+	}
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	d.cancel = cancel
+	d.running = running
+	d.name = name
+	d.timer = time.AfterFunc(timeout, cancel)
+	return ctx
+}
+
+// overran reports whether the action armed by the previous call to arm is
+// still running.
+func (d *deadlineTimer) overran() bool {
+	if d.running == nil {
+		return false
+	}
+	select {
+	case <-d.running:
+		return false
+	default:
+		return true
+	}
+}
+
+// actionTimeout returns the per-action deadline to enforce, defaulting to
+// twice the entity's action rate when the config doesn't override it.
+func (ec *EntityConfig) actionTimeout() time.Duration {
+	if configured := ec.LoadTestConfig.UserEntitiesConfiguration.ActionTimeoutMilliseconds; configured > 0 {
+		return time.Duration(configured) * time.Millisecond
+	}
+	return ec.ActionRate * 2
+}
+
 func runEntity(ec *EntityConfig) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -120,6 +188,16 @@ func runEntity(ec *EntityConfig) {
 	defer ec.StopWaitGroup.Done()
 
 	actionRateMaxVarianceMilliseconds := ec.LoadTestConfig.UserEntitiesConfiguration.ActionRateMaxVarianceMilliseconds
+	timeout := ec.actionTimeout()
+
+	entityCtx, cancelEntity := context.WithCancel(context.Background())
+	defer cancelEntity()
+	go func() {
+		<-ec.StopChannel
+		cancelEntity()
+	}()
+
+	var dt deadlineTimer
 
 	// Ensure that the entities act at uniformly distributed times.
 	now := time.Now()
@@ -136,15 +214,56 @@ func runEntity(ec *EntityConfig) {
 		case <-ec.StopChannel:
 			return
 		case <-timer.C:
+			halfVarianceDuration := time.Duration(actionRateMaxVarianceMilliseconds / 2.0)
+			randomDurationWithinVariance := time.Duration(rand.Intn(actionRateMaxVarianceMilliseconds))
+			timer.Reset(ec.ActionRate + randomDurationWithinVariance - halfVarianceDuration)
+
+			if dt.overran() {
+				// The action armed on a previous tick is still running.
+				// Firing another one on top of it would leave unbounded
+				// goroutines all sharing ec.Client/ec.Info running at
+				// once; instead report the overrun and wait for it to
+				// finish before this entity acts again.
+				ec.SendStatusActionTimeout(dt.name)
+				continue
+			}
+
 			action, err := randutil.WeightedChoice(ec.EntityActions)
 			if err != nil {
 				cmdlog.Error("Failed to pick weighted choice")
 				return
 			}
-			action.Item.(func(*EntityConfig))(ec)
-			halfVarianceDuration := time.Duration(actionRateMaxVarianceMilliseconds / 2.0)
-			randomDurationWithinVariance := time.Duration(rand.Intn(actionRateMaxVarianceMilliseconds))
-			timer.Reset(ec.ActionRate + randomDurationWithinVariance - halfVarianceDuration)
+
+			fn, ok := toEntityAction(action.Item)
+			if !ok {
+				cmdlog.Errorf("Entity action registered with unsupported signature %T", action.Item)
+				return
+			}
+			actionName := actionFuncName(action.Item)
+
+			running := make(chan struct{})
+			actionCtx := dt.arm(entityCtx, timeout, running, actionName)
+			actionStart := time.Now()
+			go func() {
+				defer close(running)
+				err := fn(actionCtx, ec)
+
+				// runEntity may already have returned by the time this
+				// action finishes (StopChannel closed while it was still
+				// in flight). Its consumer may have stopped too, so a
+				// send on StatusReportChannel below could block forever;
+				// skip it once we're shutting down.
+				select {
+				case <-ec.StopChannel:
+					return
+				default:
+				}
+
+				ec.SendStatusActionTimed(actionName, time.Since(actionStart))
+				if err != nil && actionCtx.Err() == nil {
+					ec.SendStatusActionError(actionName, err)
+				}
+			}()
 		}
 	}
 }
@@ -204,6 +323,7 @@ func websocketListen(ec *EntityConfig) {
 						continue
 					}
 					ec.WebSocketClient.Listen()
+					ec.SendStatusWebsocketReconnect()
 					break
 				}
 			}
@@ -232,6 +352,21 @@ func (config *EntityConfig) SendStatusError(err error, details string) {
 	config.SendStatus(STATUS_ERROR, err, details)
 }
 
+// SendStatusActionError reports that the named action returned an error, so
+// it can be aggregated into a per-action error rate instead of a free-form
+// message. It uses its own status, distinct from STATUS_ERROR, since
+// STATUS_ERROR's Details is a free-form message elsewhere and can't be
+// trusted to be an action name.
+func (config *EntityConfig) SendStatusActionError(name string, err error) {
+	config.SendStatus(STATUS_ACTION_ERROR, err, name)
+}
+
+// SendStatusWebsocketReconnect reports that an entity's websocket client
+// successfully reconnected after losing its connection.
+func (config *EntityConfig) SendStatusWebsocketReconnect() {
+	config.SendStatus(STATUS_WEBSOCKET_RECONNECT, nil, "")
+}
+
 func (config *EntityConfig) SendStatusFailedLaunch(err error, details string) {
 	config.SendStatus(STATUS_FAILED_LAUNCH, err, details)
 }
@@ -252,6 +387,81 @@ func (config *EntityConfig) SendStatusStopped(details string) {
 	config.SendStatus(STATUS_STOPPED, nil, details)
 }
 
+func (config *EntityConfig) SendStatusActionTimeout(details string) {
+	config.SendStatus(STATUS_ACTION_TIMEOUT, nil, details)
+}
+
+// SendStatusActionTimed reports how long a single invocation of the named
+// action took, so the metrics aggregator can build a real latency
+// histogram instead of inferring it from tick spacing. It uses its own
+// status distinct from the legacy SendStatusActionSend so a free-form
+// legacy send can't be mistaken for a timed observation.
+func (config *EntityConfig) SendStatusActionTimed(name string, duration time.Duration) {
+	config.StatusReportChannel <- UserEntityStatusReport{
+		Status:         STATUS_ACTION_COMPLETE,
+		Config:         config,
+		Details:        name,
+		ActionDuration: duration,
+	}
+}
+
+// actionFuncName derives a stable, human-readable label for an entity
+// action from its function value, e.g. "actionPostMessage", so actions can
+// be labeled in metrics without EntityAction needing a Name field. fn may
+// be an EntityAction or any function value toEntityAction accepts; the
+// name is taken from the registered function itself, before adaptation, so
+// a legacy action keeps its own name rather than that of its wrapper.
+func actionFuncName(fn interface{}) string {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		name = name[idx+1:]
+	}
+	return strings.TrimSuffix(name, "-fm")
+}
+
+// toEntityAction adapts a registered EntityActions item to the current
+// EntityAction signature. It accepts the current func(context.Context,
+// *EntityConfig) error signature directly, and also the legacy
+// func(*EntityConfig) signature some actions may still be registered
+// with, wrapping it so it always succeeds and ignores cancellation. This
+// keeps runEntity from panicking (and crash-looping via its recover) if
+// not every action has been migrated yet.
+func toEntityAction(item interface{}) (EntityAction, bool) {
+	switch fn := item.(type) {
+	case EntityAction:
+		return fn, true
+	case func(context.Context, *EntityConfig) error:
+		return fn, true
+	case func(*EntityConfig):
+		return func(ctx context.Context, ec *EntityConfig) error {
+			fn(ec)
+			return nil
+		}, true
+	default:
+		return nil, false
+	}
+}
+
+// STATUS_ACTION_TIMEOUT reports that an entity action was still running
+// when its per-action deadline elapsed, or when the following tick arrived.
+const STATUS_ACTION_TIMEOUT = 8
+
+// STATUS_ACTION_COMPLETE reports that a timed action invocation finished,
+// carrying its name and duration for the latency histogram. It is distinct
+// from STATUS_ACTION_SEND so legacy free-form action-send reports aren't
+// misread as timed observations.
+const STATUS_ACTION_COMPLETE = 9
+
+// STATUS_ACTION_ERROR reports that a named entity action returned an error,
+// carrying the action name in Details. It is distinct from STATUS_ERROR so
+// the per-action error-rate metric isn't fed free-form error messages from
+// generic STATUS_ERROR reports, which would blow up its label cardinality.
+const STATUS_ACTION_ERROR = 10
+
+// STATUS_WEBSOCKET_RECONNECT reports that an entity's websocket client
+// successfully reconnected after its connection was lost.
+const STATUS_WEBSOCKET_RECONNECT = 11
+
 func pToS(s *string) string {
 	if s == nil {
 		return ""
@@ -0,0 +1,121 @@
+// Package metrics aggregates the UserEntityStatusReport stream produced by
+// running entities into Prometheus metrics, and serves them so a loadtest
+// pod's results are comparable across runs and scrapeable in CI.
+//
+// The `loadtest all` runner is expected to construct an Aggregator with
+// NewAggregator, start Serve in its own goroutine so /metrics is live for
+// the life of the pod, and drive loadtest.RunStatusAggregation(aggregator,
+// statusReportChannel, stopChannel) to feed it reports as entities run.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/mattermost/mattermost-load-test/loadtest"
+)
+
+// Aggregator turns a stream of UserEntityStatusReport values into
+// Prometheus counters, histograms and gauges. It is safe for concurrent use
+// by multiple goroutines consuming the same StatusReportChannel.
+type Aggregator struct {
+	registry *prometheus.Registry
+
+	activeEntities prometheus.Gauge
+	actionErrors   *prometheus.CounterVec
+	actionTimeouts *prometheus.CounterVec
+	actionLatency  *prometheus.HistogramVec
+	wsReconnects   prometheus.Counter
+}
+
+// NewAggregator builds an Aggregator with its own Prometheus registry, so
+// multiple loadtest pods in a process don't collide on metric names.
+func NewAggregator() *Aggregator {
+	registry := prometheus.NewRegistry()
+
+	a := &Aggregator{
+		registry: registry,
+		activeEntities: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "loadtest_active_entities",
+			Help: "Number of entities currently running.",
+		}),
+		actionErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "loadtest_action_errors_total",
+			Help: "Number of entity actions that returned an error, by action name.",
+		}, []string{"action"}),
+		actionTimeouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "loadtest_action_timeouts_total",
+			Help: "Number of entity actions still running when their deadline or the next tick arrived.",
+		}, []string{"action"}),
+		actionLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "loadtest_action_duration_seconds",
+			Help:    "Entity action latency, by action name.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"action"}),
+		wsReconnects: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "loadtest_websocket_reconnects_total",
+			Help: "Number of websocket reconnect attempts across all entities.",
+		}),
+	}
+
+	registry.MustRegister(a.activeEntities, a.actionErrors, a.actionTimeouts, a.actionLatency, a.wsReconnects)
+	return a
+}
+
+// Record folds a single status report into the aggregate metrics.
+// STATUS_ACTION_COMPLETE reports are treated as a completed, timed action,
+// labeled by the action name carried in Details; others update the
+// gauges/counters they naturally correspond to. STATUS_ACTION_ERROR is
+// labeled by Details too, which entity.go's SendStatusActionError
+// guarantees is an action name rather than a free-form message, so the
+// per-action error counter's cardinality stays bounded; the generic
+// STATUS_ERROR and STATUS_FAILED_ACTIVE reports (whose Details is
+// free-form) are intentionally not counted here.
+func (a *Aggregator) Record(report loadtest.UserEntityStatusReport) {
+	switch report.Status {
+	case loadtest.STATUS_LAUNCHING:
+		a.activeEntities.Inc()
+	case loadtest.STATUS_STOPPED:
+		a.activeEntities.Dec()
+	case loadtest.STATUS_ACTION_COMPLETE:
+		a.actionLatency.WithLabelValues(report.Details).Observe(report.ActionDuration.Seconds())
+	case loadtest.STATUS_ACTION_TIMEOUT:
+		a.actionTimeouts.WithLabelValues(report.Details).Inc()
+	case loadtest.STATUS_ACTION_ERROR:
+		a.actionErrors.WithLabelValues(report.Details).Inc()
+	case loadtest.STATUS_WEBSOCKET_RECONNECT:
+		a.ObserveWebsocketReconnect()
+	}
+}
+
+// ObserveWebsocketReconnect records a single websocket reconnect attempt.
+// Record calls it for STATUS_WEBSOCKET_RECONNECT reports; it's exported
+// separately in case a caller ever needs to observe a reconnect outside the
+// status report stream.
+func (a *Aggregator) ObserveWebsocketReconnect() {
+	a.wsReconnects.Inc()
+}
+
+// Handler returns an http.Handler serving this Aggregator's metrics in the
+// Prometheus exposition format, suitable for mounting at /metrics.
+func (a *Aggregator) Handler() http.Handler {
+	return promhttp.HandlerFor(a.registry, promhttp.HandlerOpts{})
+}
+
+// Serve runs an HTTP server exposing /metrics on addr until ctx-equivalent
+// shutdown is triggered by the caller closing the listener; it blocks for
+// the lifetime of the loadtest pod, so callers run it in its own goroutine.
+func (a *Aggregator) Serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", a.Handler())
+	server := &http.Server{
+		Addr:         addr,
+		Handler:      mux,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+	return server.ListenAndServe()
+}
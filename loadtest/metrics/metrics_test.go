@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/mattermost/mattermost-load-test/loadtest"
+)
+
+func TestAggregatorRecordsSyntheticStream(t *testing.T) {
+	a := NewAggregator()
+
+	reports := []loadtest.UserEntityStatusReport{
+		{Status: loadtest.STATUS_LAUNCHING},
+		{Status: loadtest.STATUS_LAUNCHING},
+		{Status: loadtest.STATUS_ACTION_COMPLETE, Details: "actionPostMessage", ActionDuration: 50 * time.Millisecond},
+		{Status: loadtest.STATUS_ACTION_COMPLETE, Details: "actionPostMessage", ActionDuration: 150 * time.Millisecond},
+		{Status: loadtest.STATUS_ACTION_TIMEOUT, Details: "actionPostMessage"},
+		{Status: loadtest.STATUS_ACTION_ERROR, Details: "actionPostMessage"},
+		{Status: loadtest.STATUS_STOPPED},
+	}
+
+	for _, r := range reports {
+		a.Record(r)
+	}
+
+	if got := testutil.ToFloat64(a.activeEntities); got != 1 {
+		t.Errorf("expected 1 active entity after one launch and one stop, got %v", got)
+	}
+	if got := testutil.ToFloat64(a.actionTimeouts.WithLabelValues("actionPostMessage")); got != 1 {
+		t.Errorf("expected 1 timeout for actionPostMessage, got %v", got)
+	}
+	if got := testutil.ToFloat64(a.actionErrors.WithLabelValues("actionPostMessage")); got != 1 {
+		t.Errorf("expected 1 error for actionPostMessage, got %v", got)
+	}
+	if count := testutil.CollectAndCount(a.actionLatency); count != 1 {
+		t.Errorf("expected a single action_duration_seconds series, got %d", count)
+	}
+}
+
+// TestAggregatorIgnoresLegacyActionSend guards against the legacy,
+// free-form SendStatusActionSend reports (STATUS_ACTION_SEND) being
+// mistaken for a timed STATUS_ACTION_COMPLETE observation.
+func TestAggregatorIgnoresLegacyActionSend(t *testing.T) {
+	a := NewAggregator()
+
+	a.Record(loadtest.UserEntityStatusReport{Status: loadtest.STATUS_ACTION_SEND, Details: "some legacy detail"})
+
+	if count := testutil.CollectAndCount(a.actionLatency); count != 0 {
+		t.Errorf("expected legacy STATUS_ACTION_SEND reports not to feed the latency histogram, got %d series", count)
+	}
+}
+
+// TestAggregatorIgnoresGenericStatusError guards against feeding
+// actionErrors from STATUS_ERROR/STATUS_FAILED_ACTIVE reports, whose
+// Details is a free-form message elsewhere in the codebase rather than an
+// action name; doing so would give the per-action error counter unbounded
+// label cardinality.
+func TestAggregatorIgnoresGenericStatusError(t *testing.T) {
+	a := NewAggregator()
+
+	a.Record(loadtest.UserEntityStatusReport{Status: loadtest.STATUS_ERROR, Details: "some free-form error message"})
+	a.Record(loadtest.UserEntityStatusReport{Status: loadtest.STATUS_FAILED_ACTIVE, Details: "another free-form message"})
+
+	if count := testutil.CollectAndCount(a.actionErrors); count != 0 {
+		t.Errorf("expected generic STATUS_ERROR/STATUS_FAILED_ACTIVE reports not to feed actionErrors, got %d series", count)
+	}
+}
+
+// TestAggregatorRecordsWebsocketReconnect guards against
+// ObserveWebsocketReconnect going uncalled: entity.go reports a reconnect
+// via STATUS_WEBSOCKET_RECONNECT over the status channel (it can't call the
+// Aggregator directly without an import cycle), so Record must wire that
+// status to the counter.
+func TestAggregatorRecordsWebsocketReconnect(t *testing.T) {
+	a := NewAggregator()
+
+	a.Record(loadtest.UserEntityStatusReport{Status: loadtest.STATUS_WEBSOCKET_RECONNECT})
+	a.Record(loadtest.UserEntityStatusReport{Status: loadtest.STATUS_WEBSOCKET_RECONNECT})
+
+	if got := testutil.ToFloat64(a.wsReconnects); got != 2 {
+		t.Errorf("expected 2 websocket reconnects, got %v", got)
+	}
+}
+
+func TestHandlerExposesPrometheusFormat(t *testing.T) {
+	a := NewAggregator()
+	a.Record(loadtest.UserEntityStatusReport{Status: loadtest.STATUS_LAUNCHING})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	a.Handler().ServeHTTP(rec, req)
+
+	if !strings.Contains(rec.Body.String(), "loadtest_active_entities") {
+		t.Errorf("expected loadtest_active_entities in scrape output, got:\n%s", rec.Body.String())
+	}
+}
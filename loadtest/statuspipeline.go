@@ -0,0 +1,30 @@
+// Copyright (c) 2016 Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package loadtest
+
+// StatusRecorder records a single status report, e.g. into a metrics
+// backend. It exists so a loadtest runner can aggregate entity status
+// reports without this package importing a metrics implementation (which
+// in turn imports loadtest for UserEntityStatusReport); any type with a
+// matching Record method, such as *metrics.Aggregator, satisfies this
+// interface without either package needing to import the other.
+type StatusRecorder interface {
+	Record(report UserEntityStatusReport)
+}
+
+// RunStatusAggregation drains statusReportChannel into recorder until
+// stopChannel is closed, so the `loadtest all` runner can wire in a
+// metrics.Aggregator (started separately with its own Serve goroutine so
+// /metrics is live for the life of the pod) alongside the entities it's
+// observing. It blocks, so callers run it in its own goroutine.
+func RunStatusAggregation(recorder StatusRecorder, statusReportChannel <-chan UserEntityStatusReport, stopChannel <-chan bool) {
+	for {
+		select {
+		case <-stopChannel:
+			return
+		case report := <-statusReportChannel:
+			recorder.Record(report)
+		}
+	}
+}